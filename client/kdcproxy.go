@@ -0,0 +1,58 @@
+package client
+
+// Reference: https://msdn.microsoft.com/en-us/library/hh553223.aspx
+// [MS-KKDCP] section 2.1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/jcmturner/gokrb5/messages"
+)
+
+// kdcProxyContentType is the content-type MS-KKDCP requires for both the
+// request and the response of a KdcProxy call.
+const kdcProxyContentType = "application/kerberos"
+
+// kdcProxyTimeout bounds how long ProxyKDC waits for the KDC proxy to
+// respond, so a hung or unresponsive proxy cannot block the caller forever.
+const kdcProxyTimeout = 30 * time.Second
+
+// kdcProxyHTTPClient is used for all ProxyKDC calls; it carries
+// kdcProxyTimeout as a backstop alongside whatever deadline the caller's
+// context.Context already sets.
+var kdcProxyHTTPClient = &http.Client{Timeout: kdcProxyTimeout}
+
+// ProxyKDC sends reqBytes - the marshalled bytes of an AS-REQ or TGS-REQ - to
+// the KDC proxy at proxyURL (e.g. https://kdcproxy.example.com/KdcProxy),
+// wrapping it in a KDC-PROXY-MESSAGE targeting realm per MS-KKDCP, and
+// returns the unwrapped KDC-REP bytes ready for ASRep.Unmarshal /
+// TGSRep.Unmarshal. ctx governs cancellation of the HTTP round trip.
+func ProxyKDC(ctx context.Context, proxyURL, realm string, reqBytes []byte) ([]byte, error) {
+	wrapped, err := messages.WrapKDCProxyMessage(reqBytes, realm)
+	if err != nil {
+		return nil, fmt.Errorf("Error wrapping KDC-PROXY-MESSAGE: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, proxyURL, bytes.NewReader(wrapped))
+	if err != nil {
+		return nil, fmt.Errorf("Error building KDC proxy request: %v", err)
+	}
+	req.Header.Set("Content-Type", kdcProxyContentType)
+	resp, err := kdcProxyHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error posting to KDC proxy: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KDC proxy returned status %v", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading KDC proxy response: %v", err)
+	}
+	return messages.UnwrapKDCProxyMessage(body)
+}
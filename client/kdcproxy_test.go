@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/messages"
+)
+
+func TestProxyKDC(t *testing.T) {
+	wantRep := []byte("fake-as-rep-bytes")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != kdcProxyContentType {
+			t.Errorf("expected Content-Type %q, got %q", kdcProxyContentType, r.Header.Get("Content-Type"))
+		}
+		wrapped, err := messages.WrapKDCProxyMessage(wantRep, "TEST.GOKRB5")
+		if err != nil {
+			t.Fatalf("could not wrap test response: %v", err)
+		}
+		w.Header().Set("Content-Type", kdcProxyContentType)
+		w.Write(wrapped)
+	}))
+	defer srv.Close()
+
+	got, err := ProxyKDC(context.Background(), srv.URL, "TEST.GOKRB5", []byte("fake-as-req-bytes"))
+	if err != nil {
+		t.Fatalf("ProxyKDC failed: %v", err)
+	}
+	if string(got) != string(wantRep) {
+		t.Fatalf("expected %q, got %q", wantRep, got)
+	}
+}
+
+func TestProxyKDC_NonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := ProxyKDC(context.Background(), srv.URL, "TEST.GOKRB5", []byte("fake-as-req-bytes"))
+	if err == nil {
+		t.Fatal("expected an error for a non-200 KDC proxy response")
+	}
+}
+
+func TestProxyKDC_MalformedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a valid KDC-PROXY-MESSAGE"))
+	}))
+	defer srv.Close()
+
+	_, err := ProxyKDC(context.Background(), srv.URL, "TEST.GOKRB5", []byte("fake-as-req-bytes"))
+	if err == nil {
+		t.Fatal("expected an error for a malformed KDC-PROXY-MESSAGE response")
+	}
+}
+
+func TestProxyKDC_ContextCancelled(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := ProxyKDC(ctx, srv.URL, "TEST.GOKRB5", []byte("fake-as-req-bytes"))
+	if err == nil {
+		t.Fatal("expected an error when the context deadline is exceeded")
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// LibDefaults holds the [libdefaults] section of krb5.conf.
+type LibDefaults struct {
+	TicketLifetime time.Duration
+}
+
+// Realm holds the parsed [realms] subsection for a single realm.
+type Realm struct {
+	Realm       string
+	KDC         []string
+	KDCProxyURL string
+}
+
+// Config is the parsed form of a krb5.conf file. Callers such as
+// ASReqBuilder.Build and TGSReqBuilder.Build consult it for defaults (e.g.
+// LibDefaults.TicketLifetime); client.ProxyKDC callers look up a realm's
+// Realms[realm].KDCProxyURL to opt into MS-KKDCP tunnelling.
+type Config struct {
+	LibDefaults LibDefaults
+	Realms      map[string]*Realm
+}
+
+// NewConfig returns an empty Config ready to be populated, e.g. via
+// ParseRealms.
+func NewConfig() *Config {
+	return &Config{Realms: make(map[string]*Realm)}
+}
+
+// Load reads path as a krb5.conf file and returns the Config parsed from it.
+// This is the entry point that ties kdc_proxy (and the rest of [realms])
+// into a caller's regular config load, rather than requiring the caller to
+// locate and slice out the [realms] section itself.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading krb5.conf at %s: %v", path, err)
+	}
+	return NewConfigFromString(string(b))
+}
+
+// NewConfigFromString parses s as the contents of a krb5.conf file. Only the
+// [realms] section is currently consulted; other sections (e.g.
+// [libdefaults]) are ignored, so LibDefaults remains caller-supplied.
+func NewConfigFromString(s string) (*Config, error) {
+	c := NewConfig()
+	lines := strings.Split(s, "\n")
+	for _, section := range splitSections(lines) {
+		if section.name == "realms" {
+			c.ParseRealms(section.lines)
+		}
+	}
+	return c, nil
+}
+
+// confSection is a single top-level, bracket-delimited krb5.conf section
+// (e.g. "[realms]") together with the lines it contains.
+type confSection struct {
+	name  string
+	lines []string
+}
+
+// splitSections walks lines from a krb5.conf file and groups them by
+// top-level "[section]" heading, so each section's lines can be handed to
+// the parser that understands its contents (e.g. ParseRealms).
+func splitSections(lines []string) []confSection {
+	var sections []confSection
+	var cur *confSection
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			sections = append(sections, confSection{name: strings.TrimSuffix(strings.TrimPrefix(trimmed, "["), "]")})
+			cur = &sections[len(sections)-1]
+			continue
+		}
+		if cur != nil {
+			cur.lines = append(cur.lines, line)
+		}
+	}
+	return sections
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewConfigFromString(t *testing.T) {
+	s := `[libdefaults]
+ default_realm = EXAMPLE.COM
+
+[realms]
+ EXAMPLE.COM = {
+  kdc = kdc.example.com
+  kdc_proxy = https://kdcproxy.example.com/KdcProxy
+ }
+`
+	c, err := NewConfigFromString(s)
+	if err != nil {
+		t.Fatalf("NewConfigFromString failed: %v", err)
+	}
+
+	example, ok := c.Realms["EXAMPLE.COM"]
+	if !ok {
+		t.Fatalf("expected EXAMPLE.COM to be parsed into Config.Realms")
+	}
+	if example.KDCProxyURL != "https://kdcproxy.example.com/KdcProxy" {
+		t.Fatalf("expected EXAMPLE.COM kdc_proxy to be parsed, got %q", example.KDCProxyURL)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f, err := ioutil.TempFile("", "krb5-*.conf")
+	if err != nil {
+		t.Fatalf("could not create temp krb5.conf: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("[realms]\n EXAMPLE.COM = {\n  kdc_proxy = https://kdcproxy.example.com/KdcProxy\n }\n")
+	if err != nil {
+		t.Fatalf("could not write temp krb5.conf: %v", err)
+	}
+	f.Close()
+
+	c, err := Load(f.Name())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if c.Realms["EXAMPLE.COM"].KDCProxyURL != "https://kdcproxy.example.com/KdcProxy" {
+		t.Fatalf("expected kdc_proxy to be loaded from file, got %+v", c.Realms["EXAMPLE.COM"])
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	if _, err := Load("/nonexistent/krb5.conf"); err == nil {
+		t.Fatal("expected an error loading a nonexistent krb5.conf")
+	}
+}
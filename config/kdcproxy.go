@@ -0,0 +1,74 @@
+package config
+
+// Reference: https://msdn.microsoft.com/en-us/library/hh553223.aspx
+// [MS-KKDCP] section 2.1 describes the kdc_proxy realm setting this file
+// parses into Config.Realms.
+
+import "strings"
+
+// krb5.conf [realms] subsection keys this parser recognises.
+const (
+	kdcConfKey      = "kdc"
+	kdcProxyConfKey = "kdc_proxy"
+)
+
+// parseRealmLines scans the lines of a krb5.conf [realms] section and
+// returns each realm block as a *Realm, keyed by realm name, including
+// KDCProxyURL for callers that want to opt into tunnelling KDC requests
+// through client.ProxyKDC instead of dialling the KDC directly:
+//
+//	[realms]
+//	 EXAMPLE.COM = {
+//	  kdc = kdc.example.com
+//	  kdc_proxy = https://kdcproxy.example.com/KdcProxy
+//	 }
+func parseRealmLines(lines []string) map[string]*Realm {
+	realms := make(map[string]*Realm)
+	var cur *Realm
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasSuffix(line, "= {"):
+			name := strings.TrimSpace(strings.TrimSuffix(line, "= {"))
+			cur = &Realm{Realm: name}
+			realms[name] = cur
+		case line == "}":
+			cur = nil
+		case cur != nil:
+			key, value, ok := splitConfLine(line)
+			if !ok {
+				continue
+			}
+			switch key {
+			case kdcConfKey:
+				cur.KDC = append(cur.KDC, value)
+			case kdcProxyConfKey:
+				cur.KDCProxyURL = value
+			}
+		}
+	}
+	return realms
+}
+
+// ParseRealms parses lines as a krb5.conf [realms] section and merges the
+// resulting realms into c, so a caller driving the rest of its krb5.conf
+// load through c ends up with the kdc_proxy setting alongside everything
+// else it already loaded.
+func (c *Config) ParseRealms(lines []string) {
+	if c.Realms == nil {
+		c.Realms = make(map[string]*Realm)
+	}
+	for name, r := range parseRealmLines(lines) {
+		c.Realms[name] = r
+	}
+}
+
+// splitConfLine splits a "key = value" krb5.conf line, trimming whitespace
+// around both sides.
+func splitConfLine(line string) (key, value string, ok bool) {
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestConfig_ParseRealms(t *testing.T) {
+	lines := []string{
+		"[realms]",
+		" EXAMPLE.COM = {",
+		"  kdc = kdc.example.com",
+		"  kdc_proxy = https://kdcproxy.example.com/KdcProxy",
+		" }",
+		" OTHER.COM = {",
+		"  kdc = kdc.other.com",
+		" }",
+	}
+	c := NewConfig()
+	c.ParseRealms(lines)
+
+	example, ok := c.Realms["EXAMPLE.COM"]
+	if !ok {
+		t.Fatalf("expected EXAMPLE.COM to be parsed into Config.Realms")
+	}
+	if example.KDCProxyURL != "https://kdcproxy.example.com/KdcProxy" {
+		t.Fatalf("expected EXAMPLE.COM kdc_proxy to be parsed, got %q", example.KDCProxyURL)
+	}
+	if len(example.KDC) != 1 || example.KDC[0] != "kdc.example.com" {
+		t.Fatalf("expected EXAMPLE.COM kdc to be parsed, got %v", example.KDC)
+	}
+
+	other, ok := c.Realms["OTHER.COM"]
+	if !ok {
+		t.Fatalf("expected OTHER.COM to be parsed into Config.Realms")
+	}
+	if other.KDCProxyURL != "" {
+		t.Fatalf("did not expect OTHER.COM to have a kdc_proxy entry, got %q", other.KDCProxyURL)
+	}
+}
@@ -0,0 +1,87 @@
+package messages
+
+// Reference: https://msdn.microsoft.com/en-us/library/hh553223.aspx
+// [MS-KKDCP] section 2.2
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+
+	jtasn1 "github.com/jcmturner/asn1"
+)
+
+// marshalKDCProxyMessage mirrors KDCProxyMessage for ASN.1 (de)serialisation.
+type marshalKDCProxyMessage struct {
+	KerbMessage   []byte `asn1:"explicit,tag:0"`
+	TargetDomain  string `asn1:"generalstring,explicit,optional,tag:1"`
+	DCLocatorHint int    `asn1:"explicit,optional,tag:2"`
+}
+
+// KDCProxyMessage implements the KDC-PROXY-MESSAGE of MS-KKDCP section 2.2.2,
+// the wrapper used to tunnel KDC-REQ/KDC-REP bytes over HTTPS to a KDC proxy.
+type KDCProxyMessage struct {
+	KerbMessage   []byte
+	TargetDomain  string
+	DCLocatorHint int
+}
+
+// Marshal encodes m as a KDC-PROXY-MESSAGE, per MS-KKDCP section 2.2.2.
+func (m *KDCProxyMessage) Marshal() ([]byte, error) {
+	mm := marshalKDCProxyMessage{
+		KerbMessage:   m.KerbMessage,
+		TargetDomain:  m.TargetDomain,
+		DCLocatorHint: m.DCLocatorHint,
+	}
+	b, err := jtasn1.Marshal(mm)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling KDC-PROXY-MESSAGE: %v", err)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a KDC-PROXY-MESSAGE into m.
+func (m *KDCProxyMessage) Unmarshal(b []byte) error {
+	var mm marshalKDCProxyMessage
+	_, err := asn1.Unmarshal(b, &mm)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling KDC-PROXY-MESSAGE: %v", err)
+	}
+	m.KerbMessage = mm.KerbMessage
+	m.TargetDomain = mm.TargetDomain
+	m.DCLocatorHint = mm.DCLocatorHint
+	return nil
+}
+
+// WrapKDCProxyMessage wraps reqBytes - the marshalled bytes of an AS-REQ
+// (e.g. as produced by ASReq.Marshal) or TGS-REQ - into a KDC-PROXY-MESSAGE
+// targeting realm, per MS-KKDCP section 2.2.2: the kerb-message field is a
+// 4-byte big-endian length prefix followed by reqBytes.
+func WrapKDCProxyMessage(reqBytes []byte, realm string) ([]byte, error) {
+	lp := make([]byte, 4)
+	binary.BigEndian.PutUint32(lp, uint32(len(reqBytes)))
+	m := KDCProxyMessage{
+		KerbMessage:  append(lp, reqBytes...),
+		TargetDomain: realm,
+	}
+	return m.Marshal()
+}
+
+// UnwrapKDCProxyMessage is the inverse of WrapKDCProxyMessage: it decodes a
+// KDC-PROXY-MESSAGE and strips the 4-byte length prefix from kerb-message,
+// returning the raw AS-REP/TGS-REP (or AS-REQ/TGS-REQ) bytes it carries.
+func UnwrapKDCProxyMessage(b []byte) ([]byte, error) {
+	var m KDCProxyMessage
+	err := m.Unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.KerbMessage) < 4 {
+		return nil, fmt.Errorf("KDC-PROXY-MESSAGE kerb-message is too short to contain a length prefix")
+	}
+	l := binary.BigEndian.Uint32(m.KerbMessage[:4])
+	if uint32(len(m.KerbMessage)-4) != l {
+		return nil, fmt.Errorf("KDC-PROXY-MESSAGE kerb-message length prefix (%d) does not match payload length (%d)", l, len(m.KerbMessage)-4)
+	}
+	return m.KerbMessage[4:], nil
+}
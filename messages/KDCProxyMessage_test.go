@@ -0,0 +1,21 @@
+package messages
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrapUnwrapKDCProxyMessage(t *testing.T) {
+	req := []byte("fake AS-REQ bytes")
+	wrapped, err := WrapKDCProxyMessage(req, "TEST.GOKRB5")
+	if err != nil {
+		t.Fatalf("WrapKDCProxyMessage failed: %v", err)
+	}
+	got, err := UnwrapKDCProxyMessage(wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapKDCProxyMessage failed: %v", err)
+	}
+	if !bytes.Equal(got, req) {
+		t.Fatalf("round trip mismatch: got %q, want %q", got, req)
+	}
+}
@@ -0,0 +1,350 @@
+package messages
+
+// Reference: https://www.ietf.org/rfc/rfc4120.txt
+// Section: 5.4.1
+
+import (
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jcmturner/gokrb5/config"
+	"github.com/jcmturner/gokrb5/types"
+)
+
+// KDCOptions bits this builder knows how to validate and set. Bit numbers
+// are as defined in RFC 4120 section 5.4.1.
+const (
+	kdcOptionForwardable  = 1
+	kdcOptionProxiable    = 3
+	kdcOptionRenewable    = 8
+	kdcOptionCanonicalize = 15
+	kdcOptionRenewableOK  = 27
+	kdcOptionEncTktInSkey = 28
+)
+
+// maxReqNonce bounds the securely random Nonce a builder generates to the
+// 32-bit signed range KDCReqBody.Nonce is encoded in.
+const maxReqNonce = 1<<31 - 1
+
+// generateNonce securely generates a KDCReqBody.Nonce, shared by the
+// builders here, BuildPKINITASReq and the S4U constructors.
+func generateNonce() (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(maxReqNonce))
+	if err != nil {
+		return 0, fmt.Errorf("Error generating nonce: %v", err)
+	}
+	return int(n.Int64()), nil
+}
+
+// kdcReqBuilder accumulates the fields of a KDC-REQ-BODY under construction,
+// shared by NewASReqBuilder and NewTGSReqBuilder. It carries the
+// CNAME-IN-ADDL-TKT and additional-ticket options S4U2Proxy uses;
+// BuildPKINITASReq and the S4U constructors assemble their KDCReqBody
+// directly rather than through it.
+type kdcReqBuilder struct {
+	realm          string
+	cname          types.PrincipalName
+	hasCName       bool
+	sname          types.PrincipalName
+	hasSName       bool
+	till           time.Time
+	rtime          time.Time
+	renewable      bool
+	renewableOK    bool
+	forwardable    bool
+	proxiable      bool
+	canonicalize   bool
+	etypes         []int32
+	addresses      []types.HostAddress
+	additionalTkts []types.Ticket
+	cnameInAddlTkt bool
+	encTktInSkey   bool
+	err            error
+}
+
+// ASReqBuilder builds an ASReq. See NewASReqBuilder.
+type ASReqBuilder struct {
+	kdcReqBuilder
+}
+
+// TGSReqBuilder builds a TGSReq. See NewTGSReqBuilder.
+type TGSReqBuilder struct {
+	kdcReqBuilder
+}
+
+// NewASReqBuilder starts a fluent ASReq builder for realm.
+func NewASReqBuilder(realm string) *ASReqBuilder {
+	return &ASReqBuilder{kdcReqBuilder{realm: realm}}
+}
+
+// NewTGSReqBuilder starts a fluent TGSReq builder for realm.
+func NewTGSReqBuilder(realm string) *TGSReqBuilder {
+	return &TGSReqBuilder{kdcReqBuilder{realm: realm}}
+}
+
+// WithClient sets the CName of the request.
+func (b *ASReqBuilder) WithClient(pn types.PrincipalName) *ASReqBuilder {
+	b.cname = pn
+	b.hasCName = true
+	return b
+}
+
+// WithClient sets the CName of the request.
+func (b *TGSReqBuilder) WithClient(pn types.PrincipalName) *TGSReqBuilder {
+	b.cname = pn
+	b.hasCName = true
+	return b
+}
+
+// WithService sets the SName of the request.
+func (b *ASReqBuilder) WithService(pn types.PrincipalName) *ASReqBuilder {
+	b.sname = pn
+	b.hasSName = true
+	return b
+}
+
+// WithService sets the SName of the request.
+func (b *TGSReqBuilder) WithService(pn types.PrincipalName) *TGSReqBuilder {
+	b.sname = pn
+	b.hasSName = true
+	return b
+}
+
+// WithTill sets the requested ticket lifetime as a duration from now.
+func (b *ASReqBuilder) WithTill(d time.Duration) *ASReqBuilder {
+	b.till = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithTill sets the requested ticket lifetime as a duration from now.
+func (b *TGSReqBuilder) WithTill(d time.Duration) *TGSReqBuilder {
+	b.till = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithEtypes sets the encryption types the client advertises as acceptable.
+func (b *ASReqBuilder) WithEtypes(etypes []int32) *ASReqBuilder {
+	b.etypes = etypes
+	return b
+}
+
+// WithEtypes sets the encryption types the client advertises as acceptable.
+func (b *TGSReqBuilder) WithEtypes(etypes []int32) *TGSReqBuilder {
+	b.etypes = etypes
+	return b
+}
+
+// WithRenewable sets the RENEWABLE KDC option and the requested RTime as a
+// duration from now.
+func (b *ASReqBuilder) WithRenewable(d time.Duration) *ASReqBuilder {
+	b.renewable = true
+	b.rtime = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithRenewable sets the RENEWABLE KDC option and the requested RTime as a
+// duration from now.
+func (b *TGSReqBuilder) WithRenewable(d time.Duration) *TGSReqBuilder {
+	b.renewable = true
+	b.rtime = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithRenewableOK sets the RENEWABLE-OK KDC option and the requested RTime
+// as a duration from now, independently of WithRenewable: it tells the KDC
+// to substitute a renewable ticket if it cannot issue a non-renewable one
+// with the requested Till.
+func (b *ASReqBuilder) WithRenewableOK(d time.Duration) *ASReqBuilder {
+	b.renewableOK = true
+	b.rtime = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithRenewableOK sets the RENEWABLE-OK KDC option and the requested RTime
+// as a duration from now, independently of WithRenewable: it tells the KDC
+// to substitute a renewable ticket if it cannot issue a non-renewable one
+// with the requested Till.
+func (b *TGSReqBuilder) WithRenewableOK(d time.Duration) *TGSReqBuilder {
+	b.renewableOK = true
+	b.rtime = time.Now().UTC().Add(d)
+	return b
+}
+
+// WithForwardable sets the FORWARDABLE KDC option.
+func (b *ASReqBuilder) WithForwardable() *ASReqBuilder {
+	b.forwardable = true
+	return b
+}
+
+// WithForwardable sets the FORWARDABLE KDC option.
+func (b *TGSReqBuilder) WithForwardable() *TGSReqBuilder {
+	b.forwardable = true
+	return b
+}
+
+// WithProxiable sets the PROXIABLE KDC option.
+func (b *ASReqBuilder) WithProxiable() *ASReqBuilder {
+	b.proxiable = true
+	return b
+}
+
+// WithProxiable sets the PROXIABLE KDC option.
+func (b *TGSReqBuilder) WithProxiable() *TGSReqBuilder {
+	b.proxiable = true
+	return b
+}
+
+// WithCanonicalize sets the CANONICALIZE KDC option.
+func (b *ASReqBuilder) WithCanonicalize() *ASReqBuilder {
+	b.canonicalize = true
+	return b
+}
+
+// WithCanonicalize sets the CANONICALIZE KDC option.
+func (b *TGSReqBuilder) WithCanonicalize() *TGSReqBuilder {
+	b.canonicalize = true
+	return b
+}
+
+// WithAddresses restricts the resulting ticket to the given client addresses.
+func (b *ASReqBuilder) WithAddresses(addr ...types.HostAddress) *ASReqBuilder {
+	b.addresses = addr
+	return b
+}
+
+// WithAddresses restricts the resulting ticket to the given client addresses.
+func (b *TGSReqBuilder) WithAddresses(addr ...types.HostAddress) *TGSReqBuilder {
+	b.addresses = addr
+	return b
+}
+
+// WithAdditionalTickets sets the AdditionalTickets of the request, required
+// by ENC-TKT-IN-SKEY and CNAME-IN-ADDL-TKT.
+func (b *TGSReqBuilder) WithAdditionalTickets(tkts ...types.Ticket) *TGSReqBuilder {
+	b.additionalTkts = tkts
+	return b
+}
+
+// WithEncTktInSkey sets the ENC-TKT-IN-SKEY KDC option.
+func (b *TGSReqBuilder) WithEncTktInSkey() *TGSReqBuilder {
+	b.encTktInSkey = true
+	return b
+}
+
+// WithCNameInAddlTkt sets the CNAME-IN-ADDL-TKT KDC option used by S4U2Proxy.
+func (b *TGSReqBuilder) WithCNameInAddlTkt() *TGSReqBuilder {
+	b.cnameInAddlTkt = true
+	return b
+}
+
+// Build validates the accumulated options and returns a fully populated
+// ASReq with PVNO 5, KRB_AS_REQ MsgType, a securely generated Nonce, and a
+// 4-byte KDCOptions BitString matching what Unmarshal already normalises to.
+func (b *ASReqBuilder) Build(cfg *config.Config) (ASReq, error) {
+	var a ASReq
+	body, err := b.kdcReqBuilder.build(cfg)
+	if err != nil {
+		return a, err
+	}
+	a.PVNO = 5
+	a.MsgType = types.KrbDictionary.MsgTypesByName["KRB_AS_REQ"]
+	a.ReqBody = body
+	return a, nil
+}
+
+// Build validates the accumulated options and returns a fully populated
+// TGSReq with PVNO 5, KRB_TGS_REQ MsgType, a securely generated Nonce, and a
+// 4-byte KDCOptions BitString matching what Unmarshal already normalises to.
+func (b *TGSReqBuilder) Build(cfg *config.Config) (TGSReq, error) {
+	var t TGSReq
+	body, err := b.kdcReqBuilder.build(cfg)
+	if err != nil {
+		return t, err
+	}
+	t.PVNO = 5
+	t.MsgType = types.KrbDictionary.MsgTypesByName["KRB_TGS_REQ"]
+	t.ReqBody = body
+	return t, nil
+}
+
+// build assembles and validates the KDCReqBody shared by ASReqBuilder.Build
+// and TGSReqBuilder.Build.
+func (b *kdcReqBuilder) build(cfg *config.Config) (KDCReqBody, error) {
+	var body KDCReqBody
+	if b.err != nil {
+		return body, b.err
+	}
+	if !b.hasSName {
+		return body, fmt.Errorf("request must have a service principal set via WithService")
+	}
+
+	till := b.till
+	if till.IsZero() && cfg != nil {
+		till = time.Now().UTC().Add(cfg.LibDefaults.TicketLifetime)
+	}
+	if till.IsZero() {
+		till = time.Now().UTC().Add(24 * time.Hour)
+	}
+
+	if (b.renewable || b.renewableOK) && !(b.rtime.After(till)) {
+		return body, fmt.Errorf("RENEWABLE/RENEWABLE-OK requires a Till that supports renewal beyond the requested Till")
+	}
+	if b.encTktInSkey && len(b.additionalTkts) == 0 {
+		return body, fmt.Errorf("ENC-TKT-IN-SKEY requires at least one additional ticket")
+	}
+	if b.cnameInAddlTkt && len(b.additionalTkts) == 0 {
+		return body, fmt.Errorf("CNAME-IN-ADDL-TKT requires the user's ticket to be set as an additional ticket")
+	}
+
+	opts := asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32}
+	if b.forwardable {
+		setKDCOptionBit(&opts, kdcOptionForwardable)
+	}
+	if b.proxiable {
+		setKDCOptionBit(&opts, kdcOptionProxiable)
+	}
+	if b.renewable {
+		setKDCOptionBit(&opts, kdcOptionRenewable)
+	}
+	if b.renewableOK {
+		setKDCOptionBit(&opts, kdcOptionRenewableOK)
+	}
+	if b.canonicalize {
+		setKDCOptionBit(&opts, kdcOptionCanonicalize)
+	}
+	if b.encTktInSkey {
+		setKDCOptionBit(&opts, kdcOptionEncTktInSkey)
+	}
+	if b.cnameInAddlTkt {
+		setKDCOptionBit(&opts, kdcOptionCNameInAddlTkt)
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return body, err
+	}
+
+	etypes := make([]int, len(b.etypes))
+	for i, e := range b.etypes {
+		etypes[i] = int(e)
+	}
+
+	body = KDCReqBody{
+		KDCOptions:        opts,
+		Realm:             b.realm,
+		SName:             b.sname,
+		Till:              till,
+		RTime:             b.rtime,
+		Nonce:             nonce,
+		EType:             etypes,
+		Addresses:         b.addresses,
+		AdditionalTickets: b.additionalTkts,
+	}
+	if b.hasCName {
+		body.CName = b.cname
+	}
+	return body, nil
+}
@@ -0,0 +1,90 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/types"
+)
+
+func testService() types.PrincipalName {
+	return types.PrincipalName{NameType: 2, NameString: []string{"krbtgt", "TEST.GOKRB5"}}
+}
+
+func TestTGSReqBuilder_RenewableRequiresTillSupportingRenewal(t *testing.T) {
+	_, err := NewTGSReqBuilder("TEST.GOKRB5").
+		WithService(testService()).
+		WithTill(time.Hour).
+		WithRenewable(time.Minute).
+		Build(nil)
+	if err == nil {
+		t.Fatal("expected an error when RTime does not extend past Till, got nil")
+	}
+}
+
+func TestTGSReqBuilder_RenewableOKRequiresTillSupportingRenewal(t *testing.T) {
+	_, err := NewTGSReqBuilder("TEST.GOKRB5").
+		WithService(testService()).
+		WithTill(time.Hour).
+		WithRenewableOK(time.Minute).
+		Build(nil)
+	if err == nil {
+		t.Fatal("expected an error when RTime does not extend past Till, got nil")
+	}
+}
+
+func TestTGSReqBuilder_RenewableOKSetsOnlyRenewableOKOption(t *testing.T) {
+	ts, err := NewTGSReqBuilder("TEST.GOKRB5").
+		WithService(testService()).
+		WithTill(time.Hour).
+		WithRenewableOK(2 * time.Hour).
+		Build(nil)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	opts := ts.ReqBody.KDCOptions
+	if opts.Bytes[kdcOptionRenewableOK/8]&(1<<uint(7-kdcOptionRenewableOK%8)) == 0 {
+		t.Fatalf("expected RENEWABLE-OK bit to be set")
+	}
+	if opts.Bytes[kdcOptionRenewable/8]&(1<<uint(7-kdcOptionRenewable%8)) != 0 {
+		t.Fatalf("did not expect RENEWABLE bit to be set by WithRenewableOK alone")
+	}
+}
+
+func TestTGSReqBuilder_EncTktInSkeyRequiresAdditionalTicket(t *testing.T) {
+	_, err := NewTGSReqBuilder("TEST.GOKRB5").
+		WithService(testService()).
+		WithEncTktInSkey().
+		Build(nil)
+	if err == nil {
+		t.Fatal("expected an error when ENC-TKT-IN-SKEY is set with no additional tickets, got nil")
+	}
+}
+
+func TestTGSReqBuilder_CNameInAddlTktRequiresAdditionalTicket(t *testing.T) {
+	_, err := NewTGSReqBuilder("TEST.GOKRB5").
+		WithService(testService()).
+		WithCNameInAddlTkt().
+		Build(nil)
+	if err == nil {
+		t.Fatal("expected an error when CNAME-IN-ADDL-TKT is set with no additional tickets, got nil")
+	}
+}
+
+func TestASReqBuilder_Build(t *testing.T) {
+	a, err := NewASReqBuilder("TEST.GOKRB5").
+		WithClient(types.PrincipalName{NameType: 1, NameString: []string{"testuser"}}).
+		WithService(testService()).
+		WithForwardable().
+		WithEtypes([]int32{18, 17}).
+		Build(nil)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if a.PVNO != 5 {
+		t.Fatalf("expected PVNO 5, got %d", a.PVNO)
+	}
+	if len(a.ReqBody.KDCOptions.Bytes) != 4 {
+		t.Fatalf("expected a 4-byte KDCOptions, got %d bytes", len(a.ReqBody.KDCOptions.Bytes))
+	}
+}
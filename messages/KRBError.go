@@ -0,0 +1,72 @@
+package messages
+
+// Reference: https://www.ietf.org/rfc/rfc4120.txt
+// Section: 5.9.1
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	"github.com/jcmturner/gokrb5/types"
+	"github.com/jcmturner/gokrb5/types/asnAppTag"
+)
+
+// KRBError implements the KRB-ERROR message (RFC 4120 section 5.9.1) a KDC
+// returns instead of a KDC-REP when a request cannot be satisfied - in
+// particular the KDCErrPreauthExpired / KDCErrMorePreauthDataRequired
+// round trips FAST hardens (RFC 6113 section 5.4.3), whose EData carries a
+// PA-FX-COOKIE to echo back on the next request.
+type KRBError struct {
+	PVNO      int                 `asn1:"explicit,tag:0"`
+	MsgType   int                 `asn1:"explicit,tag:1"`
+	CTime     time.Time           `asn1:"generalized,optional,explicit,tag:2"`
+	Cusec     int                 `asn1:"optional,explicit,tag:3"`
+	STime     time.Time           `asn1:"generalized,explicit,tag:4"`
+	Susec     int                 `asn1:"explicit,tag:5"`
+	ErrorCode int                 `asn1:"explicit,tag:6"`
+	CRealm    string              `asn1:"generalstring,optional,explicit,tag:7"`
+	CName     types.PrincipalName `asn1:"optional,explicit,tag:8"`
+	Realm     string              `asn1:"generalstring,explicit,tag:9"`
+	SName     types.PrincipalName `asn1:"explicit,tag:10"`
+	EText     string              `asn1:"generalstring,optional,explicit,tag:11"`
+	EData     []byte              `asn1:"optional,explicit,tag:12"`
+}
+
+// Unmarshal a KRBError.
+func (k *KRBError) Unmarshal(b []byte) error {
+	_, err := asn1.UnmarshalWithParams(b, k, fmt.Sprintf("application,explicit,tag:%v", asnAppTag.KRBError))
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling KRB_ERROR: %v", err)
+	}
+	expectedMsgType := types.KrbDictionary.MsgTypesByName["KRB_ERROR"]
+	if k.MsgType != expectedMsgType {
+		return fmt.Errorf("Message ID does not indicate a KRB_ERROR. Expected: %v; Actual: %v", expectedMsgType, k.MsgType)
+	}
+	return nil
+}
+
+// FXCookie returns the PA-FX-COOKIE value from k.EData, for the two error
+// codes FAST defines it on - KDCErrPreauthExpired and
+// KDCErrMorePreauthDataRequired (RFC 6113 section 5.4.3). EData for these
+// codes is a METHOD-DATA (SEQUENCE OF PA-DATA); any other error code does
+// not carry a cookie and ok is false.
+func (k *KRBError) FXCookie() (cookie []byte, ok bool) {
+	if k.ErrorCode != KDCErrPreauthExpired && k.ErrorCode != KDCErrMorePreauthDataRequired {
+		return nil, false
+	}
+	if len(k.EData) == 0 {
+		return nil, false
+	}
+	var methodData []types.PAData
+	_, err := asn1.Unmarshal(k.EData, &methodData)
+	if err != nil {
+		return nil, false
+	}
+	for _, pa := range methodData {
+		if pa.PADataType == PADataFXCookie {
+			return pa.PADataValue, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,42 @@
+package messages
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/types"
+)
+
+func TestKRBError_FXCookie(t *testing.T) {
+	methodData := []types.PAData{
+		{PADataType: PADataFXCookie, PADataValue: []byte("opaque-cookie")},
+	}
+	edata, err := asn1.Marshal(methodData)
+	if err != nil {
+		t.Fatalf("could not marshal test e-data: %v", err)
+	}
+
+	k := KRBError{ErrorCode: KDCErrMorePreauthDataRequired, EData: edata}
+	cookie, ok := k.FXCookie()
+	if !ok {
+		t.Fatal("expected FXCookie to find a PA-FX-COOKIE entry")
+	}
+	if string(cookie) != "opaque-cookie" {
+		t.Fatalf("expected cookie %q, got %q", "opaque-cookie", cookie)
+	}
+}
+
+func TestKRBError_FXCookie_WrongErrorCode(t *testing.T) {
+	methodData := []types.PAData{
+		{PADataType: PADataFXCookie, PADataValue: []byte("opaque-cookie")},
+	}
+	edata, err := asn1.Marshal(methodData)
+	if err != nil {
+		t.Fatalf("could not marshal test e-data: %v", err)
+	}
+
+	k := KRBError{ErrorCode: 6, EData: edata}
+	if _, ok := k.FXCookie(); ok {
+		t.Fatal("did not expect a cookie for an unrelated error code")
+	}
+}
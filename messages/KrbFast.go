@@ -0,0 +1,257 @@
+package messages
+
+// Reference: https://www.ietf.org/rfc/rfc6113.txt
+// Section: 5.4
+
+import (
+	"encoding/asn1"
+	"fmt"
+	jtasn1 "github.com/jcmturner/asn1"
+	"github.com/jcmturner/gokrb5/crypto"
+	"github.com/jcmturner/gokrb5/types"
+)
+
+// Key usage numbers for FAST (RFC 6113 section 5.4.2/5.4.3)
+const (
+	KeyUsageFastReqChksum = 50
+	KeyUsageFastEnc       = 51
+)
+
+// PA-DATA types used by FAST (RFC 6113 section 5.4). The armored AS-REP
+// reuses PADataFXFastRequest (136) rather than a distinct reply type; 137 is
+// PA-FX-ERROR, not a "FX-FAST-REPLY" type.
+const (
+	PADataFXFastRequest = 136
+	PADataFXError       = 137
+	PADataFXCookie      = 133
+)
+
+// Armor types for KrbFastArmor (RFC 6113 section 5.4.1).
+const (
+	FXFastArmorAPRequest = 1
+)
+
+// KDC error codes introduced for hardened pre-authentication round trips
+// (RFC 6113 section 5.4.2).
+const (
+	KDCErrPreauthExpired          = 90
+	KDCErrMorePreauthDataRequired = 91
+)
+
+// KrbFastArmor implements RFC 6113 section 5.4.1.1.
+type KrbFastArmor struct {
+	ArmorType  int    `asn1:"explicit,tag:0"`
+	ArmorValue []byte `asn1:"explicit,tag:1"`
+}
+
+// KrbFastArmoredReq implements RFC 6113 section 5.4.2.
+type KrbFastArmoredReq struct {
+	Armor       KrbFastArmor        `asn1:"explicit,optional,tag:0"`
+	ReqChecksum types.Checksum      `asn1:"explicit,tag:1"`
+	EncFastReq  types.EncryptedData `asn1:"explicit,tag:2"`
+}
+
+// KrbFastReq implements RFC 6113 section 5.4.2 - the plaintext that is
+// encrypted into KrbFastArmoredReq.EncFastReq with the armor key.
+type KrbFastReq struct {
+	FastOptions asn1.BitString       `asn1:"explicit,tag:0"`
+	Padata      types.PADataSequence `asn1:"explicit,tag:1"`
+	ReqBody     KDCReqBody           `asn1:"explicit,tag:2"`
+}
+
+type marshalKrbFastReq struct {
+	FastOptions asn1.BitString       `asn1:"explicit,tag:0"`
+	Padata      types.PADataSequence `asn1:"explicit,tag:1"`
+	ReqBody     asn1.RawValue        `asn1:"explicit,tag:2"`
+}
+
+// Marshal a KrbFastReq, embedding the KDCReqBody.Marshal output as a
+// context-tagged raw value the same way ASReq.Marshal embeds its req-body.
+func (k *KrbFastReq) Marshal() ([]byte, error) {
+	rb, err := k.ReqBody.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling KrbFastReq body: %v", err)
+	}
+	m := marshalKrbFastReq{
+		FastOptions: k.FastOptions,
+		Padata:      k.Padata,
+		ReqBody: asn1.RawValue{
+			Class:      2,
+			IsCompound: true,
+			Tag:        2,
+			Bytes:      rb,
+		},
+	}
+	return jtasn1.Marshal(m)
+}
+
+// Unmarshal a KrbFastReq.
+func (k *KrbFastReq) Unmarshal(b []byte) error {
+	var m marshalKrbFastReq
+	_, err := asn1.Unmarshal(b, &m)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling KrbFastReq: %v", err)
+	}
+	var reqb KDCReqBody
+	err = reqb.Unmarshal(m.ReqBody.Bytes)
+	if err != nil {
+		return fmt.Errorf("Error processing KrbFastReq req-body: %v", err)
+	}
+	k.FastOptions = m.FastOptions
+	k.Padata = m.Padata
+	k.ReqBody = reqb
+	return nil
+}
+
+// deriveArmorKey combines the AP-REQ subkey with the armor ticket's session
+// key via the KRB-FX-CF2 key derivation function (RFC 6113 section 5.4.1.1).
+func deriveArmorKey(subkey types.EncryptionKey, tktKey types.EncryptionKey) (types.EncryptionKey, error) {
+	return crypto.KRBFX_CF2(subkey, tktKey, "subkeyarmor", "ticketarmor")
+}
+
+// WrapInFAST armors the AS-REQ per RFC 6113: it authenticates to armorTicket
+// with a fresh AP-REQ subkey, derives the armor key from that subkey and
+// armorKey via KRB-FX-CF2, and returns a copy of k whose PA-DATA is replaced
+// by a single PA-FX-FAST entry encrypting k's request body and PA-DATA under
+// it. k itself is not modified.
+func (k *ASReq) WrapInFAST(armorTicket types.Ticket, armorKey types.EncryptionKey) (ASReq, error) {
+	var a ASReq
+	subkey, err := crypto.GenerateKey(armorKey.KeyType)
+	if err != nil {
+		return a, fmt.Errorf("Error generating FAST armor subkey: %v", err)
+	}
+	auth := types.NewAuthenticator(k.ReqBody.Realm, k.ReqBody.CName)
+	auth.SubKey = subkey
+	apReq, err := NewAPReq(armorTicket, armorKey, auth)
+	if err != nil {
+		return a, fmt.Errorf("Error building FAST armor AP-REQ: %v", err)
+	}
+	armorValue, err := apReq.Marshal()
+	if err != nil {
+		return a, fmt.Errorf("Error marshalling FAST armor AP-REQ: %v", err)
+	}
+	fastKey, err := deriveArmorKey(subkey, armorKey)
+	if err != nil {
+		return a, fmt.Errorf("Error deriving FAST armor key: %v", err)
+	}
+
+	fastReq := KrbFastReq{
+		FastOptions: asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		Padata:      types.PADataSequence(k.PAData),
+		ReqBody:     k.ReqBody,
+	}
+	fb, err := fastReq.Marshal()
+	if err != nil {
+		return a, fmt.Errorf("Error marshalling inner KrbFastReq: %v", err)
+	}
+	encFastReq, err := crypto.GetEncryptedData(fb, fastKey, KeyUsageFastEnc, 1)
+	if err != nil {
+		return a, fmt.Errorf("Error encrypting KrbFastReq: %v", err)
+	}
+
+	outerBody := k.ReqBody
+	outerBody.EncAuthData = types.EncryptedData{}
+
+	rbBytes, err := outerBody.Marshal()
+	if err != nil {
+		return a, fmt.Errorf("Error marshalling outer req-body for FAST checksum: %v", err)
+	}
+	chk, err := crypto.GetChecksum(rbBytes, fastKey, KeyUsageFastReqChksum)
+	if err != nil {
+		return a, fmt.Errorf("Error computing FAST req-checksum: %v", err)
+	}
+
+	armored := KrbFastArmoredReq{
+		Armor: KrbFastArmor{
+			ArmorType:  FXFastArmorAPRequest,
+			ArmorValue: armorValue,
+		},
+		ReqChecksum: chk,
+		EncFastReq:  encFastReq,
+	}
+	ab, err := jtasn1.Marshal(armored)
+	if err != nil {
+		return a, fmt.Errorf("Error marshalling KrbFastArmoredReq: %v", err)
+	}
+
+	a = ASReq(KDCReq(*k))
+	a.ReqBody = outerBody
+	a.PAData = []types.PAData{
+		{
+			PADataType:  PADataFXFastRequest,
+			PADataValue: ab,
+		},
+	}
+	return a, nil
+}
+
+// UnwrapFAST extracts the inner KrbFastReq from an armored AS-REQ. armorKey
+// is the armor ticket's session key; the AP-REQ subkey carried in the armor
+// is recovered from it to re-derive the armor key WrapInFAST used.
+func (k *ASReq) UnwrapFAST(armorKey types.EncryptionKey) (KrbFastReq, error) {
+	var fr KrbFastReq
+	if len(k.PAData) == 0 {
+		return fr, fmt.Errorf("AS_REQ does not contain any PA-DATA")
+	}
+	var armored KrbFastArmoredReq
+	var found bool
+	for _, pa := range k.PAData {
+		if pa.PADataType == PADataFXFastRequest {
+			_, err := asn1.Unmarshal(pa.PADataValue, &armored)
+			if err != nil {
+				return fr, fmt.Errorf("Error unmarshalling PA-FX-FAST: %v", err)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fr, fmt.Errorf("AS_REQ does not contain a PA-FX-FAST PA-DATA entry")
+	}
+
+	var apReq APReq
+	err := apReq.Unmarshal(armored.Armor.ArmorValue)
+	if err != nil {
+		return fr, fmt.Errorf("Error unmarshalling FAST armor AP-REQ: %v", err)
+	}
+	auth, err := apReq.DecryptAuthenticator(armorKey)
+	if err != nil {
+		return fr, fmt.Errorf("Error decrypting FAST armor AP-REQ authenticator: %v", err)
+	}
+	fastKey, err := deriveArmorKey(auth.SubKey, armorKey)
+	if err != nil {
+		return fr, fmt.Errorf("Error deriving FAST armor key: %v", err)
+	}
+
+	rbBytes, err := k.ReqBody.Marshal()
+	if err != nil {
+		return fr, fmt.Errorf("Error marshalling outer req-body for FAST checksum: %v", err)
+	}
+	ok, err := crypto.VerifyChecksum(rbBytes, armored.ReqChecksum, fastKey, KeyUsageFastReqChksum)
+	if err != nil {
+		return fr, fmt.Errorf("Error verifying FAST req-checksum: %v", err)
+	}
+	if !ok {
+		return fr, fmt.Errorf("FAST req-checksum is invalid")
+	}
+
+	pt, err := crypto.DecryptEncPart(armored.EncFastReq, fastKey, KeyUsageFastEnc)
+	if err != nil {
+		return fr, fmt.Errorf("Error decrypting KrbFastReq: %v", err)
+	}
+	err = fr.Unmarshal(pt)
+	if err != nil {
+		return fr, fmt.Errorf("Error unmarshalling decrypted KrbFastReq: %v", err)
+	}
+	return fr, nil
+}
+
+// NewFXCookiePAData builds a PA-FX-COOKIE PA-DATA entry (RFC 6113 section
+// 5.4.3) from an opaque cookie value previously returned by the KDC in a
+// KRB_ERROR e-data field, so it can be echoed back on a subsequent request.
+func NewFXCookiePAData(cookie []byte) types.PAData {
+	return types.PAData{
+		PADataType:  PADataFXCookie,
+		PADataValue: cookie,
+	}
+}
@@ -0,0 +1,67 @@
+package messages
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/types"
+)
+
+func TestKrbFastReq_MarshalUnmarshal(t *testing.T) {
+	fr := KrbFastReq{
+		FastOptions: fourByteBitString(),
+		ReqBody: KDCReqBody{
+			KDCOptions: fourByteBitString(),
+			Realm:      "TEST.GOKRB5",
+			SName:      types.PrincipalName{NameType: 2, NameString: []string{"krbtgt", "TEST.GOKRB5"}},
+			Nonce:      12345,
+			EType:      []int{18, 17},
+		},
+	}
+	b, err := fr.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got KrbFastReq
+	err = got.Unmarshal(b)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.ReqBody.Realm != fr.ReqBody.Realm || got.ReqBody.Nonce != fr.ReqBody.Nonce {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got.ReqBody, fr.ReqBody)
+	}
+}
+
+func TestASReq_WrapUnwrapFAST(t *testing.T) {
+	armorKey := types.EncryptionKey{KeyType: 18, KeyValue: make([]byte, 32)}
+	armorTicket := types.Ticket{Realm: "TEST.GOKRB5"}
+
+	a := ASReq{
+		PVNO:    5,
+		MsgType: types.KrbDictionary.MsgTypesByName["KRB_AS_REQ"],
+		ReqBody: KDCReqBody{
+			KDCOptions: fourByteBitString(),
+			CName:      types.PrincipalName{NameType: 1, NameString: []string{"testuser"}},
+			Realm:      "TEST.GOKRB5",
+			Nonce:      54321,
+			EType:      []int{18},
+		},
+	}
+
+	wrapped, err := a.WrapInFAST(armorTicket, armorKey)
+	if err != nil {
+		t.Fatalf("WrapInFAST failed: %v", err)
+	}
+
+	fr, err := wrapped.UnwrapFAST(armorKey)
+	if err != nil {
+		t.Fatalf("UnwrapFAST failed: %v", err)
+	}
+	if fr.ReqBody.CName.NameString[0] != "testuser" {
+		t.Fatalf("unexpected inner req-body CName: %+v", fr.ReqBody.CName)
+	}
+}
+
+func fourByteBitString() asn1.BitString {
+	return asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32}
+}
@@ -0,0 +1,341 @@
+package messages
+
+// Reference: https://www.ietf.org/rfc/rfc4556.txt
+// Section: 3.2
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	jtasn1 "github.com/jcmturner/asn1"
+	"github.com/jcmturner/gokrb5/cms"
+	gokrb5crypto "github.com/jcmturner/gokrb5/crypto"
+	"github.com/jcmturner/gokrb5/types"
+)
+
+// defaultTillDuration mirrors the default ticket lifetime request the rest
+// of KRB_AS_REQ construction in this package uses.
+const defaultTillDuration = 24 * time.Hour
+
+// PA-DATA types used by PKINIT (RFC 4556 section 3.2.1/3.2.3).
+const (
+	PADataPKAsReq = 16
+	PADataPKAsRep = 17
+)
+
+// PKAuthenticator implements RFC 4556 section 3.2.1.
+type PKAuthenticator struct {
+	CuSec      int       `asn1:"explicit,tag:0"`
+	CTime      time.Time `asn1:"explicit,tag:1,generalized"`
+	Nonce      int       `asn1:"explicit,tag:2"`
+	PAChecksum []byte    `asn1:"explicit,optional,tag:3"`
+}
+
+// AuthPack implements RFC 4556 section 3.2.1. It is the structure signed by
+// the client's certificate and carried as the eContent of the PA-PK-AS-REQ
+// CMS SignedData.
+type AuthPack struct {
+	PKAuthenticator   PKAuthenticator         `asn1:"explicit,tag:0"`
+	ClientPublicValue *DHSubjectPublicKeyInfo `asn1:"explicit,optional,tag:1"`
+	SupportedCMSTypes []asn1.ObjectIdentifier `asn1:"explicit,optional,tag:2"`
+	ClientDHNonce     []byte                  `asn1:"explicit,optional,tag:3"`
+}
+
+// dhPublicNumber is the id-dh-public-number OID (RFC 3279 section 2.3.3),
+// the AlgorithmIdentifier.algorithm DHAlgorithmIdentifier carries to tell
+// the KDC that DHAlgorithmIdentifier.Parameters is a DomainParameters.
+var dhPublicNumber = asn1.ObjectIdentifier{1, 2, 840, 10046, 2, 1}
+
+// DomainParameters implements the DomainParameters ASN.1 type (RFC 3279
+// section 2.3.3): the Diffie-Hellman modulus and generator carried as
+// DHAlgorithmIdentifier.Parameters. In PKINIT's DH variant the client, not
+// the KDC, chooses the group, so these must be transmitted rather than
+// assumed - without them the KDC has no way to interpret PublicKey.
+type DomainParameters struct {
+	P *big.Int
+	G *big.Int
+	Q *big.Int `asn1:"optional"`
+}
+
+// DHAlgorithmIdentifier implements the AlgorithmIdentifier ASN.1 type (RFC
+// 3279) specialised to id-dh-public-number, as carried by
+// DHSubjectPublicKeyInfo.Algorithm.
+type DHAlgorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters DomainParameters
+}
+
+// DHSubjectPublicKeyInfo implements the SubjectPublicKeyInfo ASN.1 type (RFC
+// 3279) that AuthPack.ClientPublicValue carries in PKINIT's Diffie-Hellman
+// variant (RFC 4556 section 3.2.1): the DH domain parameters alongside the
+// client's public value, DER INTEGER-encoded and wrapped in the
+// subjectPublicKey BIT STRING per RFC 3279 section 2.3.3.
+type DHSubjectPublicKeyInfo struct {
+	Algorithm DHAlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// newDHSubjectPublicKeyInfo builds the SubjectPublicKeyInfo
+// AuthPack.ClientPublicValue carries for the DH public value pub generated
+// under dhParams, so the KDC can recover both the group and the value.
+func newDHSubjectPublicKeyInfo(pub *big.Int, dhParams DHParams) (*DHSubjectPublicKeyInfo, error) {
+	yBytes, err := asn1.Marshal(pub)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling DH public value INTEGER: %v", err)
+	}
+	return &DHSubjectPublicKeyInfo{
+		Algorithm: DHAlgorithmIdentifier{
+			Algorithm: dhPublicNumber,
+			Parameters: DomainParameters{
+				P: dhParams.P,
+				G: dhParams.G,
+			},
+		},
+		PublicKey: asn1.BitString{Bytes: yBytes, BitLength: len(yBytes) * 8},
+	}, nil
+}
+
+// PAPKAsReq implements RFC 4556 section 3.2.1 - the PA-PK-AS-REQ PA-DATA
+// value, a CMS SignedData wrapping an AuthPack.
+type PAPKAsReq struct {
+	SignedAuthPack    []byte                `asn1:"explicit,tag:0"`
+	TrustedCertifiers []types.PrincipalName `asn1:"explicit,optional,tag:1"`
+	KdcPkId           []byte                `asn1:"explicit,optional,tag:2"`
+}
+
+// DHRepInfo implements RFC 4556 section 3.2.3.1 - the Diffie-Hellman reply
+// information carried inside PA-PK-AS-REP when the Diffie-Hellman key
+// agreement variant is used. DHSignedData is a CMS SignedData whose eContent
+// is a KDCDHKeyInfo.
+type DHRepInfo struct {
+	DHSignedData  []byte `asn1:"explicit,tag:0"`
+	ServerDHNonce []byte `asn1:"explicit,optional,tag:1"`
+}
+
+// KDCDHKeyInfo implements RFC 4556 section 3.2.3.1 - the eContent of the
+// DHRepInfo.DHSignedData CMS SignedData, carrying the KDC's DH public value.
+type KDCDHKeyInfo struct {
+	SubjectPublicKey asn1.BitString `asn1:"explicit,tag:0"`
+	Nonce            int            `asn1:"explicit,tag:1"`
+	DHKeyExpiration  time.Time      `asn1:"explicit,optional,tag:2,generalized"`
+}
+
+// DHParams are the Diffie-Hellman domain parameters (modulus and generator)
+// negotiated for PKINIT's Diffie-Hellman variant (RFC 4556 section 3.2.3.1).
+type DHParams struct {
+	P *big.Int
+	G *big.Int
+}
+
+// Validate checks dhParams is complete enough to generate a DH keypair from:
+// P and G must be set, and P must be large enough that the private value's
+// range [2, P-2] is non-empty, since crypto/rand.Int panics for a
+// non-positive max.
+func (d DHParams) Validate() error {
+	if d.P == nil || d.G == nil {
+		return fmt.Errorf("PKINIT DH params are incomplete: P and G must both be set")
+	}
+	if new(big.Int).Sub(d.P, big.NewInt(3)).Sign() <= 0 {
+		return fmt.Errorf("PKINIT DH modulus P is too small to generate a private value from")
+	}
+	return nil
+}
+
+// generateDHKeyPair generates an ephemeral Diffie-Hellman private value x in
+// [2, p-2] and the corresponding public value g^x mod p under dhParams, for
+// the Diffie-Hellman variant of PKINIT's client key exchange (RFC 4556
+// section 3.2.1). The caller must hold on to priv to later derive the reply
+// key via ASRep.DecryptWithPKINIT.
+func generateDHKeyPair(dhParams DHParams) (priv *big.Int, pub *big.Int, err error) {
+	if err := dhParams.Validate(); err != nil {
+		return nil, nil, err
+	}
+	max := new(big.Int).Sub(dhParams.P, big.NewInt(3))
+	priv, err = rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error generating PKINIT DH private value: %v", err)
+	}
+	priv.Add(priv, big.NewInt(2))
+	pub = new(big.Int).Exp(dhParams.G, priv, dhParams.P)
+	return priv, pub, nil
+}
+
+// BuildPKINITASReq assembles an ASReq that authenticates with an X.509
+// certificate per RFC 4556, rather than a long-term password-derived key. The
+// PA-PK-AS-REQ PA-DATA carries a CMS SignedData, signed by privKey over cert,
+// wrapping an AuthPack whose PKAuthenticator binds the request's nonce, ctime
+// and a checksum of the request body so the reply cannot be replayed against
+// a different request. A fresh Diffie-Hellman keypair is generated under
+// dhParams and the public value carried as AuthPack.ClientPublicValue; the
+// returned private value must be passed to ASRep.DecryptWithPKINIT to derive
+// the reply key from the KDC's half of the exchange. trustedCertifiers, if
+// non-empty, is carried as-is in PA-PK-AS-REQ's optional trustedCertifiers
+// field to narrow the KDC's PA-PK-AS-REP signing certificate to one issued
+// by a certifier the client already trusts (RFC 4556 section 3.2.1); pass
+// nil when the client has no such preference.
+func BuildPKINITASReq(cname types.PrincipalName, realm string, cert *x509.Certificate, privKey crypto.Signer, dhParams DHParams, trustedCertifiers []types.PrincipalName) (ASReq, *big.Int, error) {
+	var a ASReq
+	nonce, err := generateNonce()
+	if err != nil {
+		return a, nil, err
+	}
+	body := KDCReqBody{
+		KDCOptions: asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		CName:      cname,
+		Realm:      realm,
+		Till:       time.Now().UTC().Add(defaultTillDuration),
+		Nonce:      nonce,
+		EType:      []int{18, 17},
+	}
+
+	rbBytes, err := body.Marshal()
+	if err != nil {
+		return a, nil, fmt.Errorf("Error marshalling req-body for PKINIT checksum: %v", err)
+	}
+	paChksum, err := gokrb5crypto.GetChecksumHash(rbBytes, 0)
+	if err != nil {
+		return a, nil, fmt.Errorf("Error computing PKINIT paChecksum: %v", err)
+	}
+
+	dhPriv, dhPub, err := generateDHKeyPair(dhParams)
+	if err != nil {
+		return a, nil, err
+	}
+	clientPublicValue, err := newDHSubjectPublicKeyInfo(dhPub, dhParams)
+	if err != nil {
+		return a, nil, err
+	}
+
+	authPack := AuthPack{
+		PKAuthenticator: PKAuthenticator{
+			CuSec:      time.Now().Nanosecond() / 1000,
+			CTime:      time.Now().UTC(),
+			Nonce:      body.Nonce,
+			PAChecksum: paChksum,
+		},
+		ClientPublicValue: clientPublicValue,
+	}
+	apBytes, err := jtasn1.Marshal(authPack)
+	if err != nil {
+		return a, nil, fmt.Errorf("Error marshalling AuthPack: %v", err)
+	}
+
+	signed, err := cms.NewSignedData(apBytes, cert, privKey)
+	if err != nil {
+		return a, nil, fmt.Errorf("Error signing AuthPack: %v", err)
+	}
+
+	pkAsReq := PAPKAsReq{
+		SignedAuthPack:    signed,
+		TrustedCertifiers: trustedCertifiers,
+	}
+	pkAsReqBytes, err := jtasn1.Marshal(pkAsReq)
+	if err != nil {
+		return a, nil, fmt.Errorf("Error marshalling PA-PK-AS-REQ: %v", err)
+	}
+
+	a.PVNO = 5
+	a.MsgType = types.KrbDictionary.MsgTypesByName["KRB_AS_REQ"]
+	a.ReqBody = body
+	a.PAData = []types.PAData{
+		{
+			PADataType:  PADataPKAsReq,
+			PADataValue: pkAsReqBytes,
+		},
+	}
+	return a, dhPriv, nil
+}
+
+// DecryptWithPKINIT parses the PA-PK-AS-REP PA-DATA of a, verifies the KDC's
+// CMS SignedData over the DHRepInfo against kdcCerts, checks the KDCDHKeyInfo
+// nonce against nonce (the AS-REQ's original nonce, guarding against replay
+// of a captured PA-PK-AS-REP), computes the Diffie-Hellman shared secret from
+// the KDC's signed public value and clientDHPrivateKey under dhParams, and
+// decrypts the AS-REP enc-part with the reply key derived from that secret.
+// etype identifies the encryption type negotiated for the reply
+// (aes128-cts-hmac-sha1-96 or aes256-cts-hmac-sha1-96 are supported, per
+// their k_truncate values of RFC 4556 section 3.2.3.1).
+func (a *ASRep) DecryptWithPKINIT(clientDHPrivateKey *big.Int, dhParams DHParams, nonce int, kdcCerts []*x509.Certificate, etype int32) error {
+	if len(a.PAData) == 0 {
+		return fmt.Errorf("AS_REP does not contain any PA-DATA")
+	}
+	var found bool
+	var dhRep DHRepInfo
+	for _, pa := range a.PAData {
+		if pa.PADataType == PADataPKAsRep {
+			_, err := asn1.Unmarshal(pa.PADataValue, &dhRep)
+			if err != nil {
+				return fmt.Errorf("Error unmarshalling PA-PK-AS-REP: %v", err)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("AS_REP does not contain a PA-PK-AS-REP PA-DATA entry")
+	}
+
+	eContent, err := cms.VerifySignedData(dhRep.DHSignedData, kdcCerts)
+	if err != nil {
+		return fmt.Errorf("Error verifying KDC PA-PK-AS-REP signature: %v", err)
+	}
+	var dhKeyInfo KDCDHKeyInfo
+	_, err = asn1.Unmarshal(eContent, &dhKeyInfo)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling KDCDHKeyInfo: %v", err)
+	}
+	if dhKeyInfo.Nonce != nonce {
+		return fmt.Errorf("KDCDHKeyInfo nonce %d does not match AS_REQ nonce %d", dhKeyInfo.Nonce, nonce)
+	}
+
+	serverPublic := new(big.Int).SetBytes(dhKeyInfo.SubjectPublicKey.Bytes)
+	dhSharedSecret := leftPad(new(big.Int).Exp(serverPublic, clientDHPrivateKey, dhParams.P).Bytes(), len(dhParams.P.Bytes()))
+
+	kTruncate, err := kTruncateForEType(etype)
+	if err != nil {
+		return err
+	}
+	replyKey, err := gokrb5crypto.KTruncate(dhSharedSecret, kTruncate, etype)
+	if err != nil {
+		return fmt.Errorf("Error deriving PKINIT reply key: %v", err)
+	}
+
+	err = a.DecryptEncPart(types.EncryptionKey{KeyType: etype, KeyValue: replyKey})
+	if err != nil {
+		return fmt.Errorf("Error decrypting AS_REP enc-part with PKINIT reply key: %v", err)
+	}
+	return nil
+}
+
+// leftPad left-pads b with zero bytes to length n. RFC 4556 requires the
+// Diffie-Hellman shared value ZZ to be represented as a big-endian octet
+// string the same length as the modulus (RFC 2631 section 2.1.2) before
+// K-truncate is applied; big.Int.Bytes strips leading zero bytes, so the
+// result must be re-padded to that fixed length.
+func leftPad(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b
+	}
+	padded := make([]byte, n)
+	copy(padded[n-len(b):], b)
+	return padded
+}
+
+// kTruncateForEType returns the k_truncate octet count (RFC 4556 section
+// 3.2.3.1) for the encryption types this module's PKINIT support derives keys
+// for.
+func kTruncateForEType(etype int32) (int, error) {
+	switch etype {
+	case 17: // aes128-cts-hmac-sha1-96
+		return 16, nil
+	case 18: // aes256-cts-hmac-sha1-96
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("k_truncate is not defined for etype %v", etype)
+	}
+}
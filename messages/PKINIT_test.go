@@ -0,0 +1,233 @@
+package messages
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	jtasn1 "github.com/jcmturner/asn1"
+	"github.com/jcmturner/gokrb5/cms"
+	"github.com/jcmturner/gokrb5/types"
+)
+
+// testSelfSignedCert generates an RSA key and a self-signed certificate for
+// it, standing in for a client or KDC certificate in PKINIT tests.
+func testSelfSignedCert(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return cert, key
+}
+
+// testDHParams returns small Diffie-Hellman domain parameters, sized only
+// for fast test exponentiation, not for any real security margin.
+func testDHParams() DHParams {
+	return DHParams{P: big.NewInt(23), G: big.NewInt(5)}
+}
+
+func TestAuthPack_MarshalUnmarshal(t *testing.T) {
+	ap := AuthPack{
+		PKAuthenticator: PKAuthenticator{
+			CuSec:      123456,
+			Nonce:      98765,
+			PAChecksum: []byte{1, 2, 3, 4},
+		},
+	}
+	b, err := jtasn1.Marshal(ap)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got AuthPack
+	_, err = asn1.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.PKAuthenticator.Nonce != ap.PKAuthenticator.Nonce {
+		t.Fatalf("round trip mismatch: got nonce %d, want %d", got.PKAuthenticator.Nonce, ap.PKAuthenticator.Nonce)
+	}
+}
+
+func TestBuildPKINITASReq(t *testing.T) {
+	cert, key := testSelfSignedCert(t)
+	cname := types.PrincipalName{NameType: 1, NameString: []string{"alice"}}
+	dhParams := testDHParams()
+
+	a, dhPriv, err := BuildPKINITASReq(cname, "TEST.GOKRB5", cert, key, dhParams, nil)
+	if err != nil {
+		t.Fatalf("BuildPKINITASReq failed: %v", err)
+	}
+	if dhPriv == nil {
+		t.Fatal("expected a non-nil client DH private value")
+	}
+	if len(a.PAData) != 1 || a.PAData[0].PADataType != PADataPKAsReq {
+		t.Fatalf("expected a single PA-PK-AS-REQ PA-DATA entry, got %v", a.PAData)
+	}
+
+	var pkAsReq PAPKAsReq
+	_, err = asn1.Unmarshal(a.PAData[0].PADataValue, &pkAsReq)
+	if err != nil {
+		t.Fatalf("Error unmarshalling PA-PK-AS-REQ: %v", err)
+	}
+	if len(pkAsReq.TrustedCertifiers) != 0 {
+		t.Fatalf("expected no TrustedCertifiers, got %v", pkAsReq.TrustedCertifiers)
+	}
+}
+
+func TestBuildPKINITASReq_TrustedCertifiers(t *testing.T) {
+	cert, key := testSelfSignedCert(t)
+	cname := types.PrincipalName{NameType: 1, NameString: []string{"alice"}}
+	dhParams := testDHParams()
+	certifiers := []types.PrincipalName{
+		{NameType: 1, NameString: []string{"trusted-ca"}},
+	}
+
+	a, dhPriv, err := BuildPKINITASReq(cname, "TEST.GOKRB5", cert, key, dhParams, certifiers)
+	if err != nil {
+		t.Fatalf("BuildPKINITASReq failed: %v", err)
+	}
+
+	var pkAsReq PAPKAsReq
+	_, err = asn1.Unmarshal(a.PAData[0].PADataValue, &pkAsReq)
+	if err != nil {
+		t.Fatalf("Error unmarshalling PA-PK-AS-REQ: %v", err)
+	}
+	if len(pkAsReq.TrustedCertifiers) != 1 || pkAsReq.TrustedCertifiers[0].NameString[0] != "trusted-ca" {
+		t.Fatalf("expected TrustedCertifiers to carry the caller's list, got %v", pkAsReq.TrustedCertifiers)
+	}
+
+	eContent, err := cms.VerifySignedData(pkAsReq.SignedAuthPack, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatalf("Error verifying signed AuthPack: %v", err)
+	}
+	var authPack AuthPack
+	_, err = asn1.Unmarshal(eContent, &authPack)
+	if err != nil {
+		t.Fatalf("Error unmarshalling AuthPack: %v", err)
+	}
+
+	if authPack.ClientPublicValue == nil {
+		t.Fatal("expected AuthPack.ClientPublicValue to be set")
+	}
+	wantPub := new(big.Int).Exp(dhParams.G, dhPriv, dhParams.P)
+	var gotPub big.Int
+	_, err = asn1.Unmarshal(authPack.ClientPublicValue.PublicKey.Bytes, &gotPub)
+	if err != nil {
+		t.Fatalf("Error unmarshalling DH public value INTEGER: %v", err)
+	}
+	if gotPub.Cmp(wantPub) != 0 {
+		t.Fatalf("AuthPack.ClientPublicValue.PublicKey mismatch: got %v, want %v", &gotPub, wantPub)
+	}
+	if authPack.ClientPublicValue.Algorithm.Algorithm.String() != dhPublicNumber.String() {
+		t.Fatalf("expected ClientPublicValue.Algorithm to be id-dh-public-number, got %v", authPack.ClientPublicValue.Algorithm.Algorithm)
+	}
+	if authPack.ClientPublicValue.Algorithm.Parameters.P.Cmp(dhParams.P) != 0 || authPack.ClientPublicValue.Algorithm.Parameters.G.Cmp(dhParams.G) != 0 {
+		t.Fatalf("expected ClientPublicValue to carry the DH domain parameters, got %+v", authPack.ClientPublicValue.Algorithm.Parameters)
+	}
+	if authPack.PKAuthenticator.Nonce != a.ReqBody.Nonce {
+		t.Fatalf("expected PKAuthenticator nonce %d to match req-body nonce %d", authPack.PKAuthenticator.Nonce, a.ReqBody.Nonce)
+	}
+}
+
+func TestDHParams_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  DHParams
+		wantErr bool
+	}{
+		{"valid", DHParams{P: big.NewInt(23), G: big.NewInt(5)}, false},
+		{"zero value", DHParams{}, true},
+		{"missing P", DHParams{G: big.NewInt(5)}, true},
+		{"missing G", DHParams{P: big.NewInt(23)}, true},
+		{"P too small", DHParams{P: big.NewInt(3), G: big.NewInt(5)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPKINITASReq_ZeroDHParams(t *testing.T) {
+	cert, key := testSelfSignedCert(t)
+	cname := types.PrincipalName{NameType: 1, NameString: []string{"alice"}}
+
+	_, _, err := BuildPKINITASReq(cname, "TEST.GOKRB5", cert, key, DHParams{}, nil)
+	if err == nil {
+		t.Fatal("expected an error building an AS-REQ with zero-value DHParams, got nil")
+	}
+}
+
+func TestDecryptWithPKINIT_NonceMismatch(t *testing.T) {
+	kdcCert, kdcKey := testSelfSignedCert(t)
+	dhKeyInfo := KDCDHKeyInfo{
+		SubjectPublicKey: asn1.BitString{Bytes: []byte{4}, BitLength: 8},
+		Nonce:            111,
+	}
+	dhKeyInfoBytes, err := jtasn1.Marshal(dhKeyInfo)
+	if err != nil {
+		t.Fatalf("Error marshalling KDCDHKeyInfo: %v", err)
+	}
+	signed, err := cms.NewSignedData(dhKeyInfoBytes, kdcCert, kdcKey)
+	if err != nil {
+		t.Fatalf("Error signing KDCDHKeyInfo: %v", err)
+	}
+	dhRep := DHRepInfo{DHSignedData: signed}
+	dhRepBytes, err := jtasn1.Marshal(dhRep)
+	if err != nil {
+		t.Fatalf("Error marshalling DHRepInfo: %v", err)
+	}
+
+	a := ASRep{
+		PAData: []types.PAData{
+			{PADataType: PADataPKAsRep, PADataValue: dhRepBytes},
+		},
+	}
+
+	err = a.DecryptWithPKINIT(big.NewInt(6), testDHParams(), 222, []*x509.Certificate{kdcCert}, 18)
+	if err == nil {
+		t.Fatal("expected an error when the AS_REQ nonce does not match KDCDHKeyInfo.Nonce, got nil")
+	}
+}
+
+func TestKDCDHKeyInfo_MarshalUnmarshal(t *testing.T) {
+	info := KDCDHKeyInfo{
+		SubjectPublicKey: fourByteBitString(),
+		Nonce:            42,
+	}
+	b, err := jtasn1.Marshal(info)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got KDCDHKeyInfo
+	_, err = asn1.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Nonce != info.Nonce {
+		t.Fatalf("round trip mismatch: got nonce %d, want %d", got.Nonce, info.Nonce)
+	}
+}
@@ -0,0 +1,173 @@
+package messages
+
+// Reference: https://msdn.microsoft.com/en-us/library/cc246071.aspx
+// [MS-SFU] sections 2.2.1 (PA-FOR-USER) and 3.2.5.1 (S4U2Proxy)
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	jtasn1 "github.com/jcmturner/asn1"
+	"github.com/jcmturner/gokrb5/crypto"
+	"github.com/jcmturner/gokrb5/types"
+)
+
+// PA-DATA types used by the S4U extensions ([MS-SFU] section 2.2.1).
+const (
+	PADataForUser = 129
+	PADataTGSReq  = 1
+)
+
+// Key usage number for the PA-FOR-USER checksum ([MS-SFU] section 2.2.1).
+const KeyUsagePAForUserChecksum = 17
+
+// authPackageKerberos is the auth-package value PA-FOR-USER always carries
+// for Kerberos S4U2Self requests.
+const authPackageKerberos = "Kerberos"
+
+// KDCOptions bit for cname-in-addl-tkt, used by S4U2Proxy ([MS-SFU] section
+// 3.2.5.1.1) to indicate AdditionalTickets carries the user's service ticket
+// rather than a second TGT.
+const kdcOptionCNameInAddlTkt = 14
+
+// PAForUser implements [MS-SFU] section 2.2.1.
+type PAForUser struct {
+	UserName    types.PrincipalName `asn1:"explicit,tag:0"`
+	UserRealm   string              `asn1:"generalstring,explicit,tag:1"`
+	Cksum       types.Checksum      `asn1:"explicit,tag:2"`
+	AuthPackage string              `asn1:"generalstring,explicit,tag:3"`
+}
+
+// paForUserChecksumBytes builds the byte string the PA-FOR-USER checksum is
+// computed over: the PrincipalName's name-type and name-string components,
+// userRealm, and the auth-package name, in that order, per [MS-SFU] section
+// 2.2.1.
+func paForUserChecksumBytes(userPrincipal types.PrincipalName, userRealm string) []byte {
+	var b []byte
+	nt := make([]byte, 4)
+	nt[0] = byte(userPrincipal.NameType)
+	nt[1] = byte(userPrincipal.NameType >> 8)
+	nt[2] = byte(userPrincipal.NameType >> 16)
+	nt[3] = byte(userPrincipal.NameType >> 24)
+	b = append(b, nt...)
+	for _, s := range userPrincipal.NameString {
+		b = append(b, []byte(s)...)
+	}
+	b = append(b, []byte(userRealm)...)
+	b = append(b, []byte(authPackageKerberos)...)
+	return b
+}
+
+// paForUserChecksum computes the HMAC-MD5 checksum PA-FOR-USER carries,
+// keyed with the service's long-term key, over paForUserChecksumBytes, per
+// [MS-SFU] section 2.2.1.
+func paForUserChecksum(userPrincipal types.PrincipalName, userRealm string, serviceKey types.EncryptionKey) (types.Checksum, error) {
+	return crypto.GetChecksum(paForUserChecksumBytes(userPrincipal, userRealm), serviceKey, KeyUsagePAForUserChecksum)
+}
+
+// NewS4U2SelfTGSReq builds a TGS-REQ that obtains, on behalf of
+// servicePrincipal, a service ticket to itself for userPrincipal, without
+// knowing the user's credentials ([MS-SFU] section 3.2.5.1, S4U2Self). The
+// first PA-DATA is a PA-TGS-REQ AP-REQ authenticating servicePrincipal with
+// serviceTGT/sessionKey (the TGT's session key), and the second is
+// PA-FOR-USER identifying the impersonated user, its checksum keyed with
+// longTermKey (the service's own long-term secret, per [MS-SFU] section
+// 2.2.1) - these are two different keys and must not be conflated.
+func NewS4U2SelfTGSReq(userPrincipal types.PrincipalName, userRealm string, servicePrincipal types.PrincipalName, serviceTGT types.Ticket, sessionKey types.EncryptionKey, longTermKey types.EncryptionKey) (TGSReq, error) {
+	var t TGSReq
+	apReq, err := newPATGSReqAPReq(servicePrincipal, serviceTGT, sessionKey)
+	if err != nil {
+		return t, err
+	}
+
+	cksum, err := paForUserChecksum(userPrincipal, userRealm, longTermKey)
+	if err != nil {
+		return t, fmt.Errorf("Error computing PA-FOR-USER checksum: %v", err)
+	}
+	paForUser := PAForUser{
+		UserName:    userPrincipal,
+		UserRealm:   userRealm,
+		Cksum:       cksum,
+		AuthPackage: authPackageKerberos,
+	}
+	pfuBytes, err := jtasn1.Marshal(paForUser)
+	if err != nil {
+		return t, fmt.Errorf("Error marshalling PA-FOR-USER: %v", err)
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return t, err
+	}
+	t.PVNO = 5
+	t.MsgType = types.KrbDictionary.MsgTypesByName["KRB_TGS_REQ"]
+	t.PAData = []types.PAData{
+		{PADataType: PADataTGSReq, PADataValue: apReq},
+		{PADataType: PADataForUser, PADataValue: pfuBytes},
+	}
+	t.ReqBody = KDCReqBody{
+		KDCOptions: asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		Realm:      serviceTGT.Realm,
+		SName:      servicePrincipal,
+		Till:       time.Now().UTC().Add(time.Hour),
+		Nonce:      nonce,
+		EType:      []int{18, 17},
+	}
+	return t, nil
+}
+
+// NewS4U2ProxyTGSReq builds a TGS-REQ, authenticated as servicePrincipal,
+// that exchanges userTicket (previously obtained via NewS4U2SelfTGSReq) for a
+// service ticket to targetService on behalf of the user it names - Microsoft's
+// constrained delegation protocol transition ([MS-SFU] section 3.2.5.1.1,
+// S4U2Proxy). The cname-in-addl-tkt KDC option is set and userTicket is
+// carried as the sole entry of AdditionalTickets.
+func NewS4U2ProxyTGSReq(userTicket types.Ticket, targetService types.PrincipalName, servicePrincipal types.PrincipalName, serviceTGT types.Ticket, sessionKey types.EncryptionKey) (TGSReq, error) {
+	var t TGSReq
+	apReq, err := newPATGSReqAPReq(servicePrincipal, serviceTGT, sessionKey)
+	if err != nil {
+		return t, err
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return t, err
+	}
+	opts := asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32}
+	setKDCOptionBit(&opts, kdcOptionCNameInAddlTkt)
+
+	t.PVNO = 5
+	t.MsgType = types.KrbDictionary.MsgTypesByName["KRB_TGS_REQ"]
+	t.PAData = []types.PAData{
+		{PADataType: PADataTGSReq, PADataValue: apReq},
+	}
+	t.ReqBody = KDCReqBody{
+		KDCOptions:        opts,
+		Realm:             serviceTGT.Realm,
+		SName:             targetService,
+		Till:              time.Now().UTC().Add(time.Hour),
+		Nonce:             nonce,
+		EType:             []int{18, 17},
+		AdditionalTickets: []types.Ticket{userTicket},
+	}
+	return t, nil
+}
+
+// setKDCOptionBit sets bit n (counting from the most significant bit, as
+// KDCOptions/KerberosFlags do) of a 4-byte KDCOptions BitString.
+func setKDCOptionBit(opts *asn1.BitString, n int) {
+	opts.Bytes[n/8] |= 1 << uint(7-n%8)
+}
+
+// newPATGSReqAPReq builds the AP-REQ that must always be the first PA-DATA
+// entry of a TGS-REQ, authenticating servicePrincipal (the ticket's own
+// owner, not serviceTGT.SName which is the ticket's target) to the KDC with
+// serviceTGT/sessionKey, the TGT's own session key.
+func newPATGSReqAPReq(servicePrincipal types.PrincipalName, serviceTGT types.Ticket, sessionKey types.EncryptionKey) ([]byte, error) {
+	apReq, err := NewAPReq(serviceTGT, sessionKey, types.NewAuthenticator(serviceTGT.Realm, servicePrincipal))
+	if err != nil {
+		return nil, fmt.Errorf("Error building PA-TGS-REQ AP-REQ: %v", err)
+	}
+	return apReq.Marshal()
+}
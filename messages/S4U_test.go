@@ -0,0 +1,74 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/jcmturner/gokrb5/types"
+)
+
+func testServiceTGT() (types.PrincipalName, types.Ticket, types.EncryptionKey) {
+	svc := types.PrincipalName{NameType: 1, NameString: []string{"http", "svc.test.gokrb5"}}
+	tgt := types.Ticket{Realm: "TEST.GOKRB5", SName: testService()}
+	key := types.EncryptionKey{KeyType: 18, KeyValue: make([]byte, 32)}
+	return svc, tgt, key
+}
+
+func TestPAForUserChecksumBytes_Order(t *testing.T) {
+	user := types.PrincipalName{NameType: 1, NameString: []string{"alice"}}
+	got := paForUserChecksumBytes(user, "TEST.GOKRB5")
+
+	want := []byte{1, 0, 0, 0}
+	want = append(want, []byte("alice")...)
+	want = append(want, []byte("TEST.GOKRB5")...)
+	want = append(want, []byte("Kerberos")...)
+
+	if string(got) != string(want) {
+		t.Fatalf("PA-FOR-USER checksum input mismatch:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestNewS4U2SelfTGSReq(t *testing.T) {
+	svc, tgt, key := testServiceTGT()
+	user := types.PrincipalName{NameType: 1, NameString: []string{"alice"}}
+
+	longTermKey := types.EncryptionKey{KeyType: 18, KeyValue: make([]byte, 32)}
+
+	req, err := NewS4U2SelfTGSReq(user, "TEST.GOKRB5", svc, tgt, key, longTermKey)
+	if err != nil {
+		t.Fatalf("NewS4U2SelfTGSReq failed: %v", err)
+	}
+	if len(req.PAData) != 2 {
+		t.Fatalf("expected 2 PA-DATA entries, got %d", len(req.PAData))
+	}
+	if req.PAData[0].PADataType != PADataTGSReq {
+		t.Fatalf("expected first PA-DATA to be PA-TGS-REQ, got %d", req.PAData[0].PADataType)
+	}
+	if req.PAData[1].PADataType != PADataForUser {
+		t.Fatalf("expected second PA-DATA to be PA-FOR-USER, got %d", req.PAData[1].PADataType)
+	}
+	if req.ReqBody.SName.NameType != svc.NameType || len(req.ReqBody.SName.NameString) != len(svc.NameString) {
+		t.Fatalf("expected ReqBody.SName to be the service principal %+v, got %+v", svc, req.ReqBody.SName)
+	}
+	for i, s := range svc.NameString {
+		if req.ReqBody.SName.NameString[i] != s {
+			t.Fatalf("expected ReqBody.SName to be the service principal %+v, got %+v", svc, req.ReqBody.SName)
+		}
+	}
+}
+
+func TestNewS4U2ProxyTGSReq(t *testing.T) {
+	svc, tgt, key := testServiceTGT()
+	userTicket := types.Ticket{Realm: "TEST.GOKRB5"}
+	target := types.PrincipalName{NameType: 1, NameString: []string{"cifs", "file.test.gokrb5"}}
+
+	req, err := NewS4U2ProxyTGSReq(userTicket, target, svc, tgt, key)
+	if err != nil {
+		t.Fatalf("NewS4U2ProxyTGSReq failed: %v", err)
+	}
+	if len(req.ReqBody.AdditionalTickets) != 1 {
+		t.Fatalf("expected 1 additional ticket, got %d", len(req.ReqBody.AdditionalTickets))
+	}
+	if req.ReqBody.KDCOptions.Bytes[kdcOptionCNameInAddlTkt/8]&(1<<uint(7-kdcOptionCNameInAddlTkt%8)) == 0 {
+		t.Fatal("expected CNAME-IN-ADDL-TKT KDC option to be set")
+	}
+}